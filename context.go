@@ -50,10 +50,27 @@ func (c *Context) NewDeviceByVIDPID(VID, PID int) (*Device, error) {
 	if err != nil {
 		return nil, err
 	}
-	d.usbDevice = usbDevice
+	d.finishOpen(usbDevice)
+
 	return &d, nil
 }
 
+// finishOpen fills in the parts of d that depend on the now-open
+// driver.USBDevice: the cached max packet sizes, and, for USB488 devices,
+// the SRQ goroutine.
+func (d *Device) finishOpen(usbDevice driver.USBDevice) {
+	d.usbDevice = usbDevice
+	d.bulkInMaxPacketSize = usbDevice.BulkInMaxPacketSize()
+	d.bulkOutMaxPacketSize = usbDevice.BulkOutMaxPacketSize()
+
+	if usbDevice.InterfaceProtocol() == usb488InterfaceProtocol {
+		d.usb488 = true
+		d.srqChan = make(chan StatusByte)
+		d.srqDone = make(chan struct{})
+		go d.srqLoop()
+	}
+}
+
 // NewDevice creates a new USBTMC compliant device based on the given VISA
 // address string.
 func (c *Context) NewDevice(address string) (*Device, error) {
@@ -66,9 +83,21 @@ func (c *Context) NewDevice(address string) (*Device, error) {
 
 func defaultDevice() Device {
 	return Device{
-		termChar:        '\n',
-		bTag:            1,
-		termCharEnabled: true,
+		termChar:          '\n',
+		bTag:              1,
+		statusByteTagNext: 1,
+		termCharEnabled:   true,
+
+		// USBTMC interface 0 with the conventional bulk endpoint addresses
+		// (IN addresses have the high bit set) is correct for the vast
+		// majority of instruments, which only expose a single interface.
+		ifaceNum:        0,
+		bulkInEndpoint:  0x81,
+		bulkOutEndpoint: 0x01,
+
+		readTimeout:    defaultReadTimeout,
+		writeTimeout:   defaultWriteTimeout,
+		controlTimeout: defaultControlTimeout,
 	}
 }
 
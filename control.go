@@ -0,0 +1,296 @@
+// Copyright (c) 2015-2024 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// USBTMC class-specific control request codes. Ref: USBTMC 1.00 section 4.2.
+const (
+	reqInitiateAbortBulkOut    = 1
+	reqCheckAbortBulkOutStatus = 2
+	reqInitiateAbortBulkIn     = 3
+	reqCheckAbortBulkInStatus  = 4
+	reqInitiateClear           = 5
+	reqCheckClearStatus        = 6
+	reqGetCapabilities         = 7
+	reqIndicatorPulse          = 64
+)
+
+// bmRequestType values for the class-specific control requests above. Every
+// USBTMC control request is device-to-host; only the recipient (endpoint vs.
+// interface) varies.
+const (
+	bmReqEndpointIn  = 0xa2 // device-to-host, class, endpoint
+	bmReqInterfaceIn = 0xa1 // device-to-host, class, interface
+)
+
+// USBTMC_status values returned in the first byte of most control responses.
+// Ref: USBTMC 1.00 Table 16.
+const (
+	statusSuccess               = 0x01
+	statusPending               = 0x02
+	statusFailed                = 0x80
+	statusTransferNotInProgress = 0x81
+	statusSplitNotInProgress    = 0x82
+	statusSplitInProgress       = 0x83
+)
+
+// controlError wraps a non-success USBTMC_status byte in an error that
+// identifies which request produced it.
+func controlError(op string, status byte) error {
+	return fmt.Errorf("usbtmc: %s: device returned status 0x%02x", op, status)
+}
+
+// InitiateAbortBulkOut tells the device to discard the in-progress bulk-out
+// transfer tagged with bTag. Call CheckAbortBulkOutStatus afterward to learn
+// when the abort has completed.
+func (d *Device) InitiateAbortBulkOut(bTag byte) error {
+	resp := make([]byte, 2)
+	_, err := d.usbDevice.ControlTransfer(bmReqEndpointIn, reqInitiateAbortBulkOut,
+		uint16(bTag), uint16(d.bulkOutEndpoint), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("INITIATE_ABORT_BULK_OUT", resp[0])
+	}
+	return nil
+}
+
+// CheckAbortBulkOutStatus reports whether the abort initiated by
+// InitiateAbortBulkOut has completed.
+func (d *Device) CheckAbortBulkOutStatus() (done bool, err error) {
+	resp := make([]byte, 8)
+	_, err = d.usbDevice.ControlTransfer(bmReqEndpointIn, reqCheckAbortBulkOutStatus,
+		0, uint16(d.bulkOutEndpoint), resp, d.controlTimeout)
+	if err != nil {
+		return false, err
+	}
+	switch resp[0] {
+	case statusSuccess:
+		return true, nil
+	case statusPending:
+		return false, nil
+	default:
+		return false, controlError("CHECK_ABORT_BULK_OUT_STATUS", resp[0])
+	}
+}
+
+// InitiateAbortBulkIn tells the device to discard the in-progress bulk-in
+// transfer tagged with bTag. Call CheckAbortBulkInStatus afterward to learn
+// when the abort has completed.
+func (d *Device) InitiateAbortBulkIn(bTag byte) error {
+	resp := make([]byte, 2)
+	_, err := d.usbDevice.ControlTransfer(bmReqEndpointIn, reqInitiateAbortBulkIn,
+		uint16(bTag), uint16(d.bulkInEndpoint), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("INITIATE_ABORT_BULK_IN", resp[0])
+	}
+	return nil
+}
+
+// CheckAbortBulkInStatus reports whether the abort initiated by
+// InitiateAbortBulkIn has completed.
+func (d *Device) CheckAbortBulkInStatus() (done bool, err error) {
+	resp := make([]byte, 8)
+	_, err = d.usbDevice.ControlTransfer(bmReqEndpointIn, reqCheckAbortBulkInStatus,
+		0, uint16(d.bulkInEndpoint), resp, d.controlTimeout)
+	if err != nil {
+		return false, err
+	}
+	switch resp[0] {
+	case statusSuccess:
+		return true, nil
+	case statusPending:
+		return false, nil
+	default:
+		return false, controlError("CHECK_ABORT_BULK_IN_STATUS", resp[0])
+	}
+}
+
+// pollUntilDone calls check every abortPollInterval until it reports done,
+// returns an error, or abortPollTimeout elapses overall, in which case it
+// returns an error identifying op. It backs the abort/clear poll loops,
+// which would otherwise spin forever against a device wedged in
+// statusPending.
+func (d *Device) pollUntilDone(op string, check func() (bool, error)) error {
+	deadline := time.Now().Add(abortPollTimeout)
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("usbtmc: %s: timed out after %s waiting for completion", op, abortPollTimeout)
+		}
+		time.Sleep(abortPollInterval)
+	}
+}
+
+// abortBulkOut runs the abort dance described in USBTMC 1.00 figure 8: it
+// initiates the abort for bTag and polls CheckAbortBulkOutStatus until the
+// device reports completion.
+func (d *Device) abortBulkOut(bTag byte) error {
+	if err := d.InitiateAbortBulkOut(bTag); err != nil {
+		return err
+	}
+	return d.pollUntilDone("INITIATE_ABORT_BULK_OUT", d.CheckAbortBulkOutStatus)
+}
+
+// abortBulkIn runs the abort dance described in USBTMC 1.00 figure 9: it
+// initiates the abort for bTag and polls CheckAbortBulkInStatus until the
+// device reports completion.
+func (d *Device) abortBulkIn(bTag byte) error {
+	if err := d.InitiateAbortBulkIn(bTag); err != nil {
+		return err
+	}
+	return d.pollUntilDone("INITIATE_ABORT_BULK_IN", d.CheckAbortBulkInStatus)
+}
+
+// InitiateClear starts a USBTMC clear, which resets the device's bulk
+// in/out message state (bTag sequencing and any buffered data). Call
+// CheckClearStatus afterward to learn when the clear has completed.
+func (d *Device) InitiateClear() error {
+	resp := make([]byte, 1)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqInitiateClear,
+		0, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("INITIATE_CLEAR", resp[0])
+	}
+	return nil
+}
+
+// CheckClearStatus reports whether the clear initiated by InitiateClear has
+// completed.
+func (d *Device) CheckClearStatus() (done bool, err error) {
+	resp := make([]byte, 2)
+	_, err = d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqCheckClearStatus,
+		0, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return false, err
+	}
+	switch resp[0] {
+	case statusSuccess:
+		return true, nil
+	case statusPending:
+		return false, nil
+	default:
+		return false, controlError("CHECK_CLEAR_STATUS", resp[0])
+	}
+}
+
+// Clear performs a full USBTMC clear: it initiates the clear and polls
+// CheckClearStatus until the device reports completion, then resets the
+// local bTag sequence. Use this to recover a device that has gotten stuck,
+// instead of having to close and reopen it.
+func (d *Device) Clear() error {
+	if err := d.InitiateClear(); err != nil {
+		return err
+	}
+	if err := d.pollUntilDone("INITIATE_CLEAR", d.CheckClearStatus); err != nil {
+		return err
+	}
+	d.bTag = 1
+	return nil
+}
+
+// IndicatorPulse asks the device to visibly identify itself, e.g. by
+// blinking the front-panel indicator used for remote-control status.
+func (d *Device) IndicatorPulse() error {
+	resp := make([]byte, 1)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqIndicatorPulse,
+		0, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("INDICATOR_PULSE", resp[0])
+	}
+	return nil
+}
+
+// Capabilities describes the USBTMC (and, if present, USBTMC-USB488)
+// capabilities reported by a device in response to GET_CAPABILITIES. Ref:
+// USBTMC 1.00 Table 37 and USBTMC-USB488 1.00 Tables 8-9.
+type Capabilities struct {
+	BCDUSBTMC uint16 // USBTMC version implemented, in BCD (e.g. 0x0100).
+
+	ListenOnly       bool
+	TalkOnly         bool
+	SupportsTermChar bool
+
+	// The remaining fields are only meaningful when USB488 is true.
+	USB488          bool
+	BCDUSB488       uint16 // USBTMC-USB488 version implemented, in BCD.
+	Is488Dot2       bool
+	SupportsREN     bool
+	SupportsTrigger bool
+
+	// SupportsDeviceTrigger, SupportsRemoteLocal, and SupportsServiceRequest
+	// are DT1, RL1, and SR1 from the USB488 device capabilities byte.
+	SupportsDeviceTrigger  bool
+	SupportsRemoteLocal    bool
+	SupportsServiceRequest bool
+	SupportsSCPI           bool
+}
+
+// Capabilities reads the device's USBTMC (and USBTMC-USB488, if present)
+// capabilities via GET_CAPABILITIES.
+func (d *Device) Capabilities() (Capabilities, error) {
+	resp := make([]byte, 24)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqGetCapabilities,
+		0, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if resp[0] != statusSuccess {
+		return Capabilities{}, controlError("GET_CAPABILITIES", resp[0])
+	}
+	return decodeCapabilities(resp), nil
+}
+
+func decodeCapabilities(resp []byte) Capabilities {
+	// resp[4] is the USBTMC interface capabilities byte and resp[5] is the
+	// USBTMC device capabilities byte (USBTMC 1.00 Table 37).
+	usbtmcIfaceCaps := resp[4]
+	usbtmcDevCaps := resp[5]
+	c := Capabilities{
+		BCDUSBTMC:        binary.LittleEndian.Uint16(resp[2:4]),
+		ListenOnly:       usbtmcIfaceCaps&0x01 != 0,
+		TalkOnly:         usbtmcIfaceCaps&0x02 != 0,
+		SupportsTermChar: usbtmcDevCaps&0x01 != 0,
+	}
+
+	// resp[14] is the USB488 interface capabilities byte and resp[15] is the
+	// USB488 device capabilities byte (USBTMC-USB488 1.00 Tables 8-9).
+	usb488IfaceCaps := resp[14]
+	usb488DevCaps := resp[15]
+	c.USB488 = usb488IfaceCaps != 0 || usb488DevCaps != 0 || binary.LittleEndian.Uint16(resp[12:14]) != 0
+	if c.USB488 {
+		c.BCDUSB488 = binary.LittleEndian.Uint16(resp[12:14])
+		c.SupportsTrigger = usb488IfaceCaps&0x01 != 0
+		c.SupportsREN = usb488IfaceCaps&0x02 != 0
+		c.Is488Dot2 = usb488IfaceCaps&0x04 != 0
+		c.SupportsDeviceTrigger = usb488DevCaps&0x01 != 0
+		c.SupportsRemoteLocal = usb488DevCaps&0x02 != 0
+		c.SupportsServiceRequest = usb488DevCaps&0x04 != 0
+		c.SupportsSCPI = usb488DevCaps&0x08 != 0
+	}
+	return c
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2015-2024 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		resp []byte
+		want Capabilities
+	}{
+		{
+			name: "usbtmc only, no usb488",
+			resp: func() []byte {
+				resp := make([]byte, 24)
+				binary.LittleEndian.PutUint16(resp[2:4], 0x0100)
+				resp[4] = 0x03 // ListenOnly | TalkOnly
+				resp[5] = 0x01 // SupportsTermChar
+				return resp
+			}(),
+			want: Capabilities{
+				BCDUSBTMC:        0x0100,
+				ListenOnly:       true,
+				TalkOnly:         true,
+				SupportsTermChar: true,
+			},
+		},
+		{
+			name: "usb488, every bit set",
+			resp: func() []byte {
+				resp := make([]byte, 24)
+				binary.LittleEndian.PutUint16(resp[2:4], 0x0100)
+				binary.LittleEndian.PutUint16(resp[12:14], 0x0100)
+				resp[14] = 0x07 // D0 trigger | D1 REN/GTL/LLO | D2 488.2
+				resp[15] = 0x0f // DT1 | RL1 | SR1 | SCPI
+				return resp
+			}(),
+			want: Capabilities{
+				BCDUSBTMC:              0x0100,
+				USB488:                 true,
+				BCDUSB488:              0x0100,
+				SupportsTrigger:        true,
+				SupportsREN:            true,
+				Is488Dot2:              true,
+				SupportsDeviceTrigger:  true,
+				SupportsRemoteLocal:    true,
+				SupportsServiceRequest: true,
+				SupportsSCPI:           true,
+			},
+		},
+		{
+			// Catches the D0/D2 swap that shipped in decodeCapabilities:
+			// trigger-only must not also report Is488Dot2.
+			name: "usb488, trigger only",
+			resp: func() []byte {
+				resp := make([]byte, 24)
+				binary.LittleEndian.PutUint16(resp[12:14], 0x0100)
+				resp[14] = 0x01
+				return resp
+			}(),
+			want: Capabilities{
+				USB488:          true,
+				BCDUSB488:       0x0100,
+				SupportsTrigger: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeCapabilities(tt.resp); got != tt.want {
+				t.Errorf("decodeCapabilities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,18 +11,38 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gotmc/usbtmc/driver"
 )
 
 const (
-	// This is a guess. The USB spec says the max value can be fetched from
-	// the descriptor, but the libusb documentation says packets can be up
-	// to 512 bytes.
-	// Ref: https://libusb.sourceforge.io/api-1.0/libusb_packetoverflow.html
-	maxPacketSize = 512
+	// fallbackMaxPacketSize is used in the unlikely event a driver reports a
+	// zero max packet size for a bulk endpoint. 512 matches the high-speed
+	// bulk endpoint size, the most common case.
+	fallbackMaxPacketSize = 512
 
 	usbtmcHeaderLen = 12
+
+	// defaultReadTimeout and defaultWriteTimeout bound the bulk-in and
+	// bulk-out transfers made by Read/BulkRead and Write, respectively.
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 5 * time.Second
+
+	// defaultControlTimeout bounds the class-specific control transfers used
+	// for things like the abort/clear state machine and GET_CAPABILITIES.
+	defaultControlTimeout = time.Second
+
+	// abortPollInterval is how long to wait between polls of
+	// CHECK_ABORT_BULK_{IN,OUT}_STATUS and CHECK_CLEAR_STATUS while an abort
+	// or clear is in progress.
+	abortPollInterval = 10 * time.Millisecond
+
+	// abortPollTimeout bounds the total time spent polling for an abort or
+	// clear to complete, so a device wedged in statusPending doesn't spin
+	// the poll loop forever.
+	abortPollTimeout = 5 * time.Second
 )
 
 // Device models a USBTMC device, which includes a USB device and the required
@@ -32,6 +52,45 @@ type Device struct {
 	bTag            byte
 	termChar        byte
 	termCharEnabled bool
+
+	// ifaceNum, bulkInEndpoint, and bulkOutEndpoint identify the USBTMC
+	// interface and endpoints addressed by control transfers such as the
+	// abort and clear requests, which take the endpoint or interface number
+	// as wIndex.
+	ifaceNum        byte
+	bulkInEndpoint  byte
+	bulkOutEndpoint byte
+
+	// bulkInMaxPacketSize and bulkOutMaxPacketSize are wMaxPacketSize as read
+	// from the bulk endpoint descriptors at open time. They replace the old
+	// hardcoded 512-byte guess and vary with the device's USB speed: 64
+	// (full-speed), 512 (high-speed), or 1024 (SuperSpeed).
+	bulkInMaxPacketSize  uint16
+	bulkOutMaxPacketSize uint16
+
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	controlTimeout time.Duration
+
+	// usb488, srqChan, and srqDone back the USBTMC-USB488 SRQ support in
+	// usb488.go. srqChan and srqDone are nil for non-USB488 devices.
+	usb488  bool
+	srqChan chan StatusByte
+	srqDone chan struct{}
+
+	// srqMu guards statusByteWait and statusByteTag, which let srqLoop hand
+	// an interrupt-IN notification to a waiting ReadStatusByte call instead
+	// of publishing it on srqChan, when the notification's tag reconciles
+	// with that call's READ_STATUS_BYTE request.
+	srqMu          sync.Mutex
+	statusByteWait chan StatusByte
+	statusByteTag  byte
+
+	// statusByteTagNext is the last tag allocated to a READ_STATUS_BYTE
+	// request by nextStatusByteTag. It's a separate sequence from bTag
+	// because USB488 restricts this particular tag to 0x02-0x7F, unlike the
+	// full 1-255 range used by bulk transfers.
+	statusByteTagNext byte
 }
 
 // Write creates the appropriate USBMTC header, writes the header and data on
@@ -41,7 +100,10 @@ func (d *Device) Write(p []byte) (n int, err error) {
 	// FIXME(mdr): I need to change this so that I look at the size of the buf
 	// being written to see if it can truly fit into one transfer, and if not
 	// split it into multiple transfers.
-	maxTransferSize := 512
+	maxTransferSize := int(d.bulkOutMaxPacketSize)
+	if maxTransferSize == 0 {
+		maxTransferSize = fallbackMaxPacketSize
+	}
 	for pos := 0; pos < len(p); {
 		d.bTag = nextbTag(d.bTag)
 		thisLen := len(p[pos:])
@@ -55,8 +117,11 @@ func (d *Device) Write(p []byte) (n int, err error) {
 			alignment := bytes.Repeat([]byte{0x00}, numAlignment)
 			data = append(data, alignment...)
 		}
-		_, err := d.usbDevice.Write(data)
+		_, err := d.usbDevice.Write(data, d.writeTimeout)
 		if err != nil {
+			if abortErr := d.abortBulkOut(d.bTag); abortErr != nil {
+				debug.Printf("Write: abort after write error failed: %v\n", abortErr)
+			}
 			return pos, err
 		}
 		pos += thisLen
@@ -70,7 +135,7 @@ func (d *Device) doRead(p []byte, useTermChar bool) (n int, err error) {
 	d.bTag = nextbTag(d.bTag)
 	header := encodeMsgInBulkOutHeader(d.bTag, uint32(len(p)),
 		useTermChar && d.termCharEnabled, d.termChar)
-	if _, err = d.usbDevice.Write(header[:]); err != nil {
+	if _, err = d.usbDevice.Write(header[:], d.writeTimeout); err != nil {
 		return 0, err
 	}
 	debug.Printf("sent reqdevdepmsgin hdr %v (data len %v)\n",
@@ -117,6 +182,9 @@ func (d *Device) doRead(p []byte, useTermChar bool) (n int, err error) {
 			[]string{"", "..."}[dumpTrunc])
 
 		if err != nil {
+			if abortErr := d.abortBulkIn(d.bTag); abortErr != nil {
+				debug.Printf("doRead: abort after read error failed: %v\n", abortErr)
+			}
 			return pos, err
 		}
 		if resp == 0 {
@@ -188,22 +256,25 @@ func (d *Device) readRemoveHeader(p []byte) (n int, transfer int, transferAttr b
 	// libusb documentation is full of dire warnings about what happens if
 	// the incoming data exceeds the receiving buffer[^1]. It recommends
 	// making sure the incoming buffer is a multiple of the maximum packet
-	// size. We don't know the actual maximum packet size, but we think we
-	// know the maximum packet size, so rounding the transfer size up to the
-	// next multiple of the maximum packet size should make it difficult for
-	// incoming data to overflow.
+	// size, which we read from the bulk-in endpoint descriptor at open
+	// time, so rounding the transfer size up to the next multiple of that
+	// should make it difficult for incoming data to overflow.
 	//
 	// [^1]: https://libusb.sourceforge.io/api-1.0/libusb_packetoverflow.html
+	maxPacketSize := int(d.bulkInMaxPacketSize)
+	if maxPacketSize == 0 {
+		maxPacketSize = fallbackMaxPacketSize
+	}
 	tempSz := len(p) + usbtmcHeaderLen
-	if m := tempSz % 512; m != 0 {
-		tempSz += 512 - m
+	if m := tempSz % maxPacketSize; m != 0 {
+		tempSz += maxPacketSize - m
 	}
 
 	debug.Printf("readRemoveHeader: len(p) %v, w/hdr %v -> buf size %v\n",
 		len(p), len(p)+usbtmcHeaderLen, tempSz)
 	temp := make([]byte, tempSz)
 
-	n, err = d.usbDevice.Read(temp)
+	n, err = d.usbDevice.Read(temp, d.readTimeout)
 	if err != nil {
 		return 0, 0, 0, err
 	}
@@ -230,11 +301,15 @@ func (d *Device) readRemoveHeader(p []byte) (n int, transfer int, transferAttr b
 }
 
 func (d *Device) readKeepHeader(p []byte) (n int, err error) {
-	return d.usbDevice.Read(p)
+	return d.usbDevice.Read(p, d.readTimeout)
 }
 
-// Close closes the underlying USB device.
+// Close closes the underlying USB device, first signaling the SRQ goroutine
+// to stop if one was started. It does not wait for that goroutine to exit.
 func (d *Device) Close() error {
+	if d.srqDone != nil {
+		close(d.srqDone)
+	}
 	return d.usbDevice.Close()
 }
 
@@ -265,6 +340,10 @@ func (d *Device) Query(s string) (string, error) {
 	}
 
 	// Try to ensure a single-packet read
+	maxPacketSize := int(d.bulkInMaxPacketSize)
+	if maxPacketSize == 0 {
+		maxPacketSize = fallbackMaxPacketSize
+	}
 	p := make([]byte, maxPacketSize-usbtmcHeaderLen)
 	n, err := d.Read(p)
 	if err != nil {
@@ -0,0 +1,103 @@
+// Copyright (c) 2015-2024 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+// Package driver defines the interfaces a concrete USB backend must
+// implement in order to be used by the usbtmc package.
+package driver
+
+import "time"
+
+// Driver creates a new Context for the underlying USB library.
+type Driver interface {
+	NewContext() (Context, error)
+}
+
+// Context represents the state held by the underlying USB library and is
+// used to find and open USBTMC devices.
+type Context interface {
+	NewDeviceByVIDPID(VID, PID int) (USBDevice, error)
+
+	// ListDevices walks every attached USB device and returns a descriptor
+	// for each. The usbtmc package is responsible for filtering the result
+	// down to USBTMC interfaces.
+	ListDevices() ([]DeviceDescriptor, error)
+
+	// OpenDevice opens the device described by desc, as previously returned
+	// by ListDevices.
+	OpenDevice(desc DeviceDescriptor) (USBDevice, error)
+
+	Close() error
+	SetDebugLevel(level int)
+}
+
+// DeviceDescriptor describes a single attached USB device, as enumerated by
+// Context.ListDevices, before it's known whether the device speaks USBTMC.
+type DeviceDescriptor struct {
+	VID, PID                            int
+	SerialNumber, Manufacturer, Product string
+	Bus, Address                        int
+
+	// InterfaceNumber, InterfaceClass, InterfaceSubClass, and
+	// InterfaceProtocol describe the first interface on the device whose
+	// class is USB_CLASS_APP_SPECIFIC (0xFE); the usbtmc package uses
+	// InterfaceClass/InterfaceSubClass to decide whether the device is a
+	// USBTMC (subclass 0x03) instrument, and InterfaceProtocol to decide
+	// whether it additionally implements USBTMC-USB488 (protocol 0x01).
+	InterfaceNumber   byte
+	InterfaceClass    byte
+	InterfaceSubClass byte
+	InterfaceProtocol byte
+
+	// BulkInEndpoint, BulkOutEndpoint, and InterruptInEndpoint are the
+	// endpoint addresses found on InterfaceNumber. InterruptInEndpoint is 0
+	// if the interface has no interrupt-IN endpoint.
+	BulkInEndpoint      byte
+	BulkOutEndpoint     byte
+	InterruptInEndpoint byte
+}
+
+// USBDevice represents a USB device as exposed by the underlying USB
+// library. usbtmc.Device is built on top of a USBDevice.
+type USBDevice interface {
+	Read(p []byte, timeout time.Duration) (n int, err error)
+	Write(p []byte, timeout time.Duration) (n int, err error)
+	Close() error
+
+	// Cancel cancels any transfer currently in flight on the device (e.g.
+	// via libusb_cancel_transfer), unblocking a pending Read or Write so a
+	// context-aware caller can return promptly once its context is done.
+	// Drivers that can't cancel in-flight transfers may implement this as a
+	// no-op; the caller will then only unblock once the transfer's own
+	// timeout elapses.
+	Cancel() error
+
+	// ControlTransfer performs a control transfer on endpoint 0, following
+	// the same bmRequestType/bRequest/wValue/wIndex layout used by libusb's
+	// libusb_control_transfer (and, by extension, gousb and hanwen/usb). For
+	// an IN transfer (bmRequestType bit 7 set), data is filled in by the
+	// device and the number of bytes read is returned; for an OUT transfer,
+	// data is sent to the device.
+	ControlTransfer(bmRequestType, bRequest byte, wValue, wIndex uint16, data []byte, timeout time.Duration) (n int, err error)
+
+	// InterruptRead reads a single packet from the device's interrupt-IN
+	// endpoint, such as the USBTMC-USB488 SRQ notification endpoint.
+	InterruptRead(p []byte, timeout time.Duration) (n int, err error)
+
+	// InterfaceSubClass returns the bInterfaceSubClass of the USBTMC
+	// interface. Every USBTMC interface, USB488 or not, reports 0x03 here.
+	InterfaceSubClass() byte
+
+	// InterfaceProtocol returns the bInterfaceProtocol of the USBTMC
+	// interface, used to detect USB488 support: USB488 devices report 0x01,
+	// plain USBTMC devices report 0x00.
+	InterfaceProtocol() byte
+
+	// BulkInMaxPacketSize and BulkOutMaxPacketSize return wMaxPacketSize, in
+	// bytes, from the bulk-in and bulk-out endpoint descriptors
+	// respectively. The value depends on the device's negotiated USB speed:
+	// 64 for full-speed, 512 for high-speed, or 1024 for SuperSpeed.
+	BulkInMaxPacketSize() uint16
+	BulkOutMaxPacketSize() uint16
+}
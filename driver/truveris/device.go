@@ -5,7 +5,11 @@
 
 package truveris
 
-import "github.com/truveris/gousb/usb"
+import (
+	"time"
+
+	"github.com/truveris/gousb/usb"
+)
 
 // Device represents a USB device not a USBMTC device.
 type Device struct {
@@ -13,6 +17,13 @@ type Device struct {
 	BulkInEndpoint      usb.Endpoint
 	BulkOutEndpoint     usb.Endpoint
 	InterruptInEndpoint usb.Endpoint
+
+	// IfaceSubClass and IfaceProtocol hold the bInterfaceSubClass and
+	// bInterfaceProtocol of the claimed USBTMC interface, as read from the
+	// interface descriptor at open time. IfaceSubClass is 0x03 for every
+	// USBTMC interface; IfaceProtocol is 0x01 for USBTMC-USB488 devices.
+	IfaceSubClass byte
+	IfaceProtocol byte
 }
 
 // Close closes the Device.
@@ -25,18 +36,67 @@ func (d *Device) String() string {
 	return d.USBDevice.Descriptor.SerialNumber
 }
 
-// Write writes to the USB device's bulk out endpoint.
-func (d *Device) Write(p []byte) (n int, err error) {
+// Write writes to the USB device's bulk out endpoint. gousb's usb.Endpoint
+// doesn't take a per-call timeout, so timeout is unused here; it's honored
+// by drivers built on libraries that support it.
+func (d *Device) Write(p []byte, timeout time.Duration) (n int, err error) {
 	return d.BulkOutEndpoint.Write(p)
 }
 
 // WriteString writes the given string to the Device and returns the number
 // of bytes written along with an error code.
-func (d *Device) WriteString(s string) (n int, err error) {
-	return d.Write([]byte(s))
+func (d *Device) WriteString(s string, timeout time.Duration) (n int, err error) {
+	return d.Write([]byte(s), timeout)
 }
 
-// Read reads from the USB device's bulk in endpoint.
-func (d *Device) Read(p []byte) (n int, err error) {
+// Read reads from the USB device's bulk in endpoint. timeout is unused for
+// the same reason noted in Write.
+func (d *Device) Read(p []byte, timeout time.Duration) (n int, err error) {
 	return d.BulkInEndpoint.Read(p)
 }
+
+// Cancel cancels any transfer in flight on the device. gousb's usb.Device
+// doesn't expose libusb_cancel_transfer, so this is a no-op: a caller
+// blocked in Read/Write will only unblock once that call's own timeout
+// elapses.
+func (d *Device) Cancel() error {
+	return nil
+}
+
+// ControlTransfer performs a control transfer on endpoint 0. gousb's
+// usb.Device.Control doesn't take a per-call timeout, so timeout is unused
+// here; it's honored by drivers built on libraries that support it.
+func (d *Device) ControlTransfer(bmRequestType, bRequest byte, wValue, wIndex uint16, data []byte, timeout time.Duration) (n int, err error) {
+	return d.USBDevice.Control(bmRequestType, bRequest, wValue, wIndex, data)
+}
+
+// InterruptRead reads a single packet from the device's interrupt-in
+// endpoint, e.g. USBTMC-USB488 SRQ notifications. timeout is unused for the
+// same reason noted in ControlTransfer.
+func (d *Device) InterruptRead(p []byte, timeout time.Duration) (n int, err error) {
+	return d.InterruptInEndpoint.Read(p)
+}
+
+// InterfaceSubClass returns the bInterfaceSubClass of the claimed USBTMC
+// interface.
+func (d *Device) InterfaceSubClass() byte {
+	return d.IfaceSubClass
+}
+
+// InterfaceProtocol returns the bInterfaceProtocol of the claimed USBTMC
+// interface.
+func (d *Device) InterfaceProtocol() byte {
+	return d.IfaceProtocol
+}
+
+// BulkInMaxPacketSize returns wMaxPacketSize from the bulk-in endpoint
+// descriptor.
+func (d *Device) BulkInMaxPacketSize() uint16 {
+	return d.BulkInEndpoint.Descriptor.MaxPacketSize
+}
+
+// BulkOutMaxPacketSize returns wMaxPacketSize from the bulk-out endpoint
+// descriptor.
+func (d *Device) BulkOutMaxPacketSize() uint16 {
+	return d.BulkOutEndpoint.Descriptor.MaxPacketSize
+}
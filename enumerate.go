@@ -0,0 +1,81 @@
+// Copyright (c) 2015-2024 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import "github.com/gotmc/usbtmc/driver"
+
+// usbtmcInterfaceClass is the bInterfaceClass value (USB_CLASS_APP_SPECIFIC)
+// used by every USBTMC interface, USB488 or not.
+const usbtmcInterfaceClass = 0xfe
+
+// DeviceInfo describes a USBTMC instrument found by Context.ListDevices,
+// with enough information to tell instruments apart (e.g. by serial number)
+// before opening one with Context.OpenDevice.
+type DeviceInfo struct {
+	VID, PID                            int
+	SerialNumber, Manufacturer, Product string
+	USB488                              bool
+	Bus, Address                        int
+	BulkInEndpoint                      byte
+	BulkOutEndpoint                     byte
+	InterruptInEndpoint                 byte
+
+	desc driver.DeviceDescriptor
+}
+
+// ListDevices walks every attached USB device and returns a DeviceInfo for
+// each one presenting a USBTMC interface (bInterfaceClass 0xFE,
+// bInterfaceSubClass 0x03).
+func (c *Context) ListDevices() ([]*DeviceInfo, error) {
+	descs, err := c.libusbContext.ListDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*DeviceInfo
+	for _, desc := range descs {
+		if desc.InterfaceClass != usbtmcInterfaceClass {
+			continue
+		}
+		if desc.InterfaceSubClass != usb488InterfaceSubClass {
+			continue
+		}
+		infos = append(infos, &DeviceInfo{
+			VID:                 desc.VID,
+			PID:                 desc.PID,
+			SerialNumber:        desc.SerialNumber,
+			Manufacturer:        desc.Manufacturer,
+			Product:             desc.Product,
+			USB488:              desc.InterfaceProtocol == usb488InterfaceProtocol,
+			Bus:                 desc.Bus,
+			Address:             desc.Address,
+			BulkInEndpoint:      desc.BulkInEndpoint,
+			BulkOutEndpoint:     desc.BulkOutEndpoint,
+			InterruptInEndpoint: desc.InterruptInEndpoint,
+			desc:                desc,
+		})
+	}
+	return infos, nil
+}
+
+// OpenDevice opens the instrument described by info, as previously returned
+// by ListDevices. Use this instead of NewDeviceByVIDPID to pick a specific
+// instrument by serial number when several identical ones are attached.
+func (c *Context) OpenDevice(info *DeviceInfo) (*Device, error) {
+	d := defaultDevice()
+	d.bTag = c.startTag
+	d.ifaceNum = info.desc.InterfaceNumber
+	d.bulkInEndpoint = info.BulkInEndpoint
+	d.bulkOutEndpoint = info.BulkOutEndpoint
+
+	usbDevice, err := c.libusbContext.OpenDevice(info.desc)
+	if err != nil {
+		return nil, err
+	}
+	d.finishOpen(usbDevice)
+
+	return &d, nil
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2015-2024 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SetReadTimeout sets how long Read and BulkRead wait for the device's
+// bulk-in endpoint before giving up.
+func (d *Device) SetReadTimeout(timeout time.Duration) {
+	d.readTimeout = timeout
+}
+
+// SetWriteTimeout sets how long Write waits on the device's bulk-out
+// endpoint before giving up.
+func (d *Device) SetWriteTimeout(timeout time.Duration) {
+	d.writeTimeout = timeout
+}
+
+// SetControlTimeout sets how long the class-specific control requests (the
+// abort/clear state machine, GET_CAPABILITIES, and the USB488 requests)
+// wait for a response before giving up.
+func (d *Device) SetControlTimeout(timeout time.Duration) {
+	d.controlTimeout = timeout
+}
+
+// rwResult carries the result of a Read or Write run on a goroutine so
+// ReadContext/WriteContext can select between it and ctx.Done().
+type rwResult struct {
+	n   int
+	err error
+}
+
+// ReadContext is Read, but once ctx is done it cancels the in-flight bulk-in
+// transfer, waits for the underlying Read to return, runs the bulk-in abort
+// dance so the device isn't left mid-transfer, and returns ctx.Err().
+//
+// Cancellation via driver.USBDevice.Cancel is best-effort: the only shipped
+// driver (truveris) implements Cancel as a no-op, so with it ctx expiring
+// doesn't actually interrupt the underlying Read, which only returns once
+// its own timeout elapses or the transfer otherwise completes.
+func (d *Device) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	done := make(chan rwResult, 1)
+	go func() {
+		n, err := d.Read(p)
+		done <- rwResult{n, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-ctx.Done():
+		if err := d.usbDevice.Cancel(); err != nil {
+			debug.Printf("ReadContext: Cancel: %v\n", err)
+		}
+		select {
+		case r := <-done:
+			// The read had already returned, successfully or not, when
+			// checked here; the device isn't mid-transfer, and its result
+			// shouldn't be discarded, so return it and skip the abort
+			// dance below.
+			return r.n, r.err
+		default:
+		}
+		<-done
+		if err := d.abortBulkIn(d.bTag); err != nil {
+			debug.Printf("ReadContext: abort after cancel failed: %v\n", err)
+		}
+		return 0, ctx.Err()
+	}
+}
+
+// WriteContext is Write, but once ctx is done it cancels the in-flight
+// bulk-out transfer, waits for the underlying Write to return, runs the
+// bulk-out abort dance so the device isn't left mid-transfer, and returns
+// ctx.Err().
+//
+// Cancellation via driver.USBDevice.Cancel is best-effort: the only shipped
+// driver (truveris) implements Cancel as a no-op, so with it ctx expiring
+// doesn't actually interrupt the underlying Write, which only returns once
+// its own timeout elapses or the transfer otherwise completes.
+func (d *Device) WriteContext(ctx context.Context, p []byte) (n int, err error) {
+	done := make(chan rwResult, 1)
+	go func() {
+		n, err := d.Write(p)
+		done <- rwResult{n, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-ctx.Done():
+		if err := d.usbDevice.Cancel(); err != nil {
+			debug.Printf("WriteContext: Cancel: %v\n", err)
+		}
+		select {
+		case r := <-done:
+			// The write had already returned, successfully or not, when
+			// checked here; the device isn't mid-transfer, so return its
+			// result and skip the abort dance below.
+			return r.n, r.err
+		default:
+		}
+		<-done
+		if err := d.abortBulkOut(d.bTag); err != nil {
+			debug.Printf("WriteContext: abort after cancel failed: %v\n", err)
+		}
+		return 0, ctx.Err()
+	}
+}
+
+// CommandContext is Command, but it returns ctx.Err() once ctx is done, per
+// the semantics of WriteContext.
+func (d *Device) CommandContext(ctx context.Context, format string, a ...interface{}) error {
+	cmd := format
+	if a != nil {
+		cmd = fmt.Sprintf(format, a...)
+	}
+	_, err := d.WriteContext(ctx, []byte(strings.TrimSpace(cmd)+"\n"))
+	return err
+}
+
+// QueryContext is Query, but it returns ctx.Err() once ctx is done, per the
+// semantics of WriteContext and ReadContext.
+func (d *Device) QueryContext(ctx context.Context, s string) (string, error) {
+	if err := d.CommandContext(ctx, s); err != nil {
+		return "", err
+	}
+
+	maxPacketSize := int(d.bulkInMaxPacketSize)
+	if maxPacketSize == 0 {
+		maxPacketSize = fallbackMaxPacketSize
+	}
+	p := make([]byte, maxPacketSize-usbtmcHeaderLen)
+	n, err := d.ReadContext(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s", p[:n]), nil
+}
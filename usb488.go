@@ -0,0 +1,281 @@
+// Copyright (c) 2015-2024 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"fmt"
+	"time"
+)
+
+// usb488InterfaceSubClass is the bInterfaceSubClass value of every USBTMC
+// interface, USB488 or not; it does not by itself indicate USB488 support.
+const usb488InterfaceSubClass = 0x03
+
+// usb488InterfaceProtocol is the bInterfaceProtocol value that marks a
+// USBTMC interface as also implementing USBTMC-USB488.
+const usb488InterfaceProtocol = 0x01
+
+// USBTMC-USB488 class-specific control request codes. Ref: USBTMC-USB488
+// 1.00 section 4.2.
+const (
+	reqReadStatusByte = 128
+	reqRENControl     = 160
+	reqGoToLocal      = 161
+	reqLocalLockout   = 162
+	reqTrigger        = 163
+)
+
+// srqPacketLen is the size, in bytes, of an interrupt-IN SRQ notification:
+// one byte of bNotify1 followed by one byte of status byte (STB).
+const srqPacketLen = 2
+
+// srqNotifyValid is bit 7 of bNotify1, which USBTMC-USB488 always sets on an
+// interrupt-IN SRQ notification. The remaining 7 bits carry the bTag being
+// reconciled, or 0 for an unsolicited SRQ.
+const srqNotifyValid = 0x80
+
+// interruptReadTimeout bounds each poll of the interrupt-IN endpoint made by
+// the SRQ goroutine. It's short relative to controlTimeout because a timed
+// out read just means no SRQ has arrived yet, not a device error.
+const interruptReadTimeout = 100 * time.Millisecond
+
+// srqReadErrorBackoff bounds how often srqLoop retries a non-timeout
+// InterruptRead error, so a persistently failing read (e.g. a closed or
+// absent interrupt-IN endpoint) doesn't spin the loop at 100% CPU.
+const srqReadErrorBackoff = 500 * time.Millisecond
+
+// maxSRQReadErrors is the number of consecutive non-timeout InterruptRead
+// errors srqLoop tolerates before giving up on SRQ delivery for this device.
+const maxSRQReadErrors = 10
+
+// timeoutError is implemented by a driver's InterruptRead error when the
+// poll simply elapsed with no data, as opposed to a real device/transport
+// failure. It mirrors the net.Error convention most Go USB/IO libraries
+// follow.
+type timeoutError interface {
+	Timeout() bool
+}
+
+// errNotUSB488 is returned by the USB488-only methods when called on a
+// device that doesn't implement the USBTMC-USB488 subclass.
+var errNotUSB488 = fmt.Errorf("usbtmc: device does not implement USBTMC-USB488")
+
+// StatusByte is a IEEE 488.2 status byte delivered over the interrupt-IN
+// endpoint in response to a service request (SRQ), paired with the bTag of
+// the READ_STATUS_BYTE request it answers (0 for an unsolicited SRQ).
+type StatusByte struct {
+	Tag byte
+	STB byte
+}
+
+// IsUSB488 reports whether the device implements the USBTMC-USB488 subclass,
+// as detected from the interface descriptor when the device was opened.
+func (d *Device) IsUSB488() bool {
+	return d.usb488
+}
+
+// SRQChan returns the channel on which USBTMC-USB488 service request (SRQ)
+// notifications are delivered. The channel is closed when the device is
+// closed, or earlier if the SRQ goroutine gives up after maxSRQReadErrors
+// consecutive interrupt-IN read errors. It returns nil if the device is not
+// a USB488 device.
+func (d *Device) SRQChan() <-chan StatusByte {
+	return d.srqChan
+}
+
+// srqLoop reads SRQ notifications off the interrupt-IN endpoint and
+// forwards them on srqChan until srqDone is closed or maxSRQReadErrors
+// consecutive InterruptRead errors occur. If a notification's tag
+// reconciles with an outstanding ReadStatusByte call, it's delivered to that
+// call instead of srqChan.
+func (d *Device) srqLoop() {
+	defer close(d.srqChan)
+	buf := make([]byte, srqPacketLen)
+	consecutiveErrors := 0
+	for {
+		select {
+		case <-d.srqDone:
+			return
+		default:
+		}
+
+		n, err := d.usbDevice.InterruptRead(buf, interruptReadTimeout)
+		if err != nil {
+			if te, ok := err.(timeoutError); ok && te.Timeout() {
+				// No SRQ arrived during this poll; that's the expected
+				// steady state, so retry immediately.
+				consecutiveErrors = 0
+				continue
+			}
+			// A real error (e.g. a closed or absent interrupt-IN endpoint)
+			// won't resolve itself by spinning; back off before retrying,
+			// and stop the loop instead of busy-looping forever if it
+			// keeps happening.
+			consecutiveErrors++
+			debug.Printf("srqLoop: InterruptRead: %v (%d consecutive)\n", err, consecutiveErrors)
+			if consecutiveErrors >= maxSRQReadErrors {
+				debug.Printf("srqLoop: giving up after %d consecutive InterruptRead errors\n", consecutiveErrors)
+				return
+			}
+			select {
+			case <-time.After(srqReadErrorBackoff):
+			case <-d.srqDone:
+				return
+			}
+			continue
+		}
+		consecutiveErrors = 0
+		if n < srqPacketLen {
+			continue
+		}
+		sb := StatusByte{Tag: buf[0] &^ srqNotifyValid, STB: buf[1]}
+
+		d.srqMu.Lock()
+		wait, waitTag := d.statusByteWait, d.statusByteTag
+		d.srqMu.Unlock()
+		if sb.Tag != 0 && wait != nil && sb.Tag == waitTag {
+			wait <- sb
+			continue
+		}
+
+		select {
+		case d.srqChan <- sb:
+		case <-d.srqDone:
+			return
+		}
+	}
+}
+
+// nextStatusByteTag returns the tag following tag for use in a
+// READ_STATUS_BYTE request, wrapping from 0x7f back to 0x02. USB488 1.00
+// §4.3.1 restricts this bTag to 0x02-0x7F (0 is reserved for an unsolicited
+// SRQ and 0x80 is srqNotifyValid), unlike the full 1-255 range used by bulk
+// transfers, so it's tracked separately from Device.bTag.
+func nextStatusByteTag(tag byte) byte {
+	if tag < 0x02 || tag >= 0x7f {
+		return 0x02
+	}
+	return tag + 1
+}
+
+// ReadStatusByte reads the device's IEEE 488.2 status byte via the
+// READ_STATUS_BYTE control request. Per USBTMC-USB488 1.00 section 3.2,
+// when the device has an interrupt-IN endpoint (which, in this package, is
+// every USB488 device) the control response's status byte is reserved and
+// the real STB instead arrives as an interrupt-IN notification tagged with
+// the same bTag; ReadStatusByte hands that reconciliation off to srqLoop and
+// waits for it here rather than trusting the control response.
+func (d *Device) ReadStatusByte() (StatusByte, error) {
+	if !d.usb488 {
+		return StatusByte{}, errNotUSB488
+	}
+	d.statusByteTagNext = nextStatusByteTag(d.statusByteTagNext)
+	tag := d.statusByteTagNext
+
+	wait := make(chan StatusByte, 1)
+	d.srqMu.Lock()
+	d.statusByteWait, d.statusByteTag = wait, tag
+	d.srqMu.Unlock()
+	defer func() {
+		d.srqMu.Lock()
+		if d.statusByteWait == wait {
+			d.statusByteWait = nil
+		}
+		d.srqMu.Unlock()
+	}()
+
+	resp := make([]byte, 3)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqReadStatusByte,
+		uint16(tag), uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return StatusByte{}, err
+	}
+	if resp[0] != statusSuccess {
+		return StatusByte{}, controlError("READ_STATUS_BYTE", resp[0])
+	}
+
+	select {
+	case sb := <-wait:
+		return sb, nil
+	case <-time.After(d.controlTimeout):
+		return StatusByte{}, fmt.Errorf("usbtmc: READ_STATUS_BYTE: timed out waiting for interrupt-in reconciliation")
+	}
+}
+
+// RENControl enables or disables the device's remote enable (REN) line.
+func (d *Device) RENControl(enable bool) error {
+	if !d.usb488 {
+		return errNotUSB488
+	}
+	var wValue uint16
+	if enable {
+		wValue = 1
+	}
+	resp := make([]byte, 1)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqRENControl,
+		wValue, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("REN_CONTROL", resp[0])
+	}
+	return nil
+}
+
+// GoToLocal releases the device from remote control, returning it to local
+// (front-panel) control.
+func (d *Device) GoToLocal() error {
+	if !d.usb488 {
+		return errNotUSB488
+	}
+	resp := make([]byte, 1)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqGoToLocal,
+		0, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("GO_TO_LOCAL", resp[0])
+	}
+	return nil
+}
+
+// LocalLockout disables the device's front-panel controls so it can only be
+// operated remotely.
+func (d *Device) LocalLockout() error {
+	if !d.usb488 {
+		return errNotUSB488
+	}
+	resp := make([]byte, 1)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqLocalLockout,
+		0, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("LOCAL_LOCKOUT", resp[0])
+	}
+	return nil
+}
+
+// Trigger sends the USBTMC-USB488 group execute trigger (GET) to the
+// device, equivalent to the IEEE 488.1 GET bus command.
+func (d *Device) Trigger() error {
+	if !d.usb488 {
+		return errNotUSB488
+	}
+	resp := make([]byte, 1)
+	_, err := d.usbDevice.ControlTransfer(bmReqInterfaceIn, reqTrigger,
+		0, uint16(d.ifaceNum), resp, d.controlTimeout)
+	if err != nil {
+		return err
+	}
+	if resp[0] != statusSuccess {
+		return controlError("TRIGGER", resp[0])
+	}
+	return nil
+}
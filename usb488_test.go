@@ -0,0 +1,123 @@
+// Copyright (c) 2015-2024 The usbtmc developers. All rights reserved.
+// Project site: https://github.com/gotmc/usbtmc
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbtmc
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeTimeoutError is a driver InterruptRead error that behaves like a
+// real poll timeout, satisfying the timeoutError interface srqLoop checks
+// for.
+type fakeTimeoutError struct{}
+
+func (fakeTimeoutError) Error() string { return "fake: interrupt read timed out" }
+func (fakeTimeoutError) Timeout() bool { return true }
+
+// fakeUSB488Device is a driver.USBDevice that feeds InterruptRead from pkts
+// and, on a READ_STATUS_BYTE control request, pushes back a reconciling
+// interrupt-IN notification carrying the request's own tag.
+type fakeUSB488Device struct {
+	pkts chan []byte
+}
+
+func (f *fakeUSB488Device) Read(p []byte, timeout time.Duration) (int, error)  { return 0, nil }
+func (f *fakeUSB488Device) Write(p []byte, timeout time.Duration) (int, error) { return 0, nil }
+func (f *fakeUSB488Device) Close() error                                      { return nil }
+func (f *fakeUSB488Device) Cancel() error                                     { return nil }
+
+func (f *fakeUSB488Device) ControlTransfer(bmRequestType, bRequest byte, wValue, wIndex uint16, data []byte, timeout time.Duration) (int, error) {
+	if bRequest == reqReadStatusByte {
+		f.pkts <- []byte{byte(wValue) | srqNotifyValid, 0x55}
+	}
+	data[0] = statusSuccess
+	return len(data), nil
+}
+
+func (f *fakeUSB488Device) InterruptRead(p []byte, timeout time.Duration) (int, error) {
+	select {
+	case pkt := <-f.pkts:
+		return copy(p, pkt), nil
+	case <-time.After(timeout):
+		return 0, fakeTimeoutError{}
+	}
+}
+
+func (f *fakeUSB488Device) InterfaceSubClass() byte      { return usb488InterfaceSubClass }
+func (f *fakeUSB488Device) InterfaceProtocol() byte      { return usb488InterfaceProtocol }
+func (f *fakeUSB488Device) BulkInMaxPacketSize() uint16  { return 512 }
+func (f *fakeUSB488Device) BulkOutMaxPacketSize() uint16 { return 512 }
+
+func newTestUSB488Device(fake *fakeUSB488Device) *Device {
+	return &Device{
+		usbDevice:         fake,
+		usb488:            true,
+		srqChan:           make(chan StatusByte),
+		srqDone:           make(chan struct{}),
+		controlTimeout:    time.Second,
+		statusByteTagNext: 1,
+	}
+}
+
+func TestReadStatusByteReconcilesWithInterruptIn(t *testing.T) {
+	fake := &fakeUSB488Device{pkts: make(chan []byte, 1)}
+	d := newTestUSB488Device(fake)
+	go d.srqLoop()
+	defer close(d.srqDone)
+
+	sb, err := d.ReadStatusByte()
+	if err != nil {
+		t.Fatalf("ReadStatusByte() error = %v", err)
+	}
+	// The request allocates tag 2 (the sequence starts at 0x02), which the
+	// fake echoes back in the interrupt-IN notification.
+	if want := (StatusByte{Tag: 2, STB: 0x55}); sb != want {
+		t.Errorf("ReadStatusByte() = %+v, want %+v", sb, want)
+	}
+}
+
+func TestReadStatusByteReconcilesAcrossTagWrap(t *testing.T) {
+	fake := &fakeUSB488Device{pkts: make(chan []byte, 1)}
+	d := newTestUSB488Device(fake)
+	d.statusByteTagNext = 0x7f // next tag wraps back to 0x02
+	go d.srqLoop()
+	defer close(d.srqDone)
+
+	if _, err := d.ReadStatusByte(); err != nil {
+		t.Fatalf("ReadStatusByte() error = %v", err)
+	}
+
+	// A second call allocates tag 0x03 and must not be confused by the
+	// wraparound.
+	sb, err := d.ReadStatusByte()
+	if err != nil {
+		t.Fatalf("ReadStatusByte() error = %v", err)
+	}
+	if want := (StatusByte{Tag: 0x03, STB: 0x55}); sb != want {
+		t.Errorf("ReadStatusByte() = %+v, want %+v", sb, want)
+	}
+}
+
+func TestSRQLoopForwardsUnsolicitedSRQ(t *testing.T) {
+	fake := &fakeUSB488Device{pkts: make(chan []byte, 1)}
+	d := newTestUSB488Device(fake)
+	go d.srqLoop()
+	defer close(d.srqDone)
+
+	// An unsolicited SRQ carries tag 0 (no READ_STATUS_BYTE to reconcile
+	// against) and must be published on SRQChan, not dropped.
+	fake.pkts <- []byte{srqNotifyValid, 0x42}
+
+	select {
+	case sb := <-d.SRQChan():
+		if want := (StatusByte{Tag: 0, STB: 0x42}); sb != want {
+			t.Errorf("SRQChan() received %+v, want %+v", sb, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for unsolicited SRQ on SRQChan")
+	}
+}